@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// codecPrefix is a 1-byte framing prefix written ahead of every encoded
+// payload so Decode can tell which codec produced it, independent of which
+// codec's Decode method is actually called.
+type codecPrefix byte
+
+const (
+	jsonCodecPrefix    codecPrefix = 0x00
+	msgpackCodecPrefix codecPrefix = 0x01
+)
+
+// Codec encodes/decodes domain values for storage, e.g. in the cache.
+type Codec interface {
+	Encode(v any) ([]byte, error)
+	Decode(b []byte, v any) error
+}
+
+// activeCodec is the Codec used by MarshalBinary on FeatureFlag/Target/Segment.
+// It defaults to JSONCodec and should be overridden once at startup, before
+// the cache is used, via SetActiveCodec.
+var activeCodec Codec = JSONCodec{}
+
+// SetActiveCodec sets the Codec used when marshaling FeatureFlag/Target/Segment.
+// Decoding always inspects the framing prefix regardless of the active codec,
+// so payloads written by a previous codec keep deserializing during rollout.
+func SetActiveCodec(c Codec) {
+	activeCodec = c
+}
+
+// decodeFramed dispatches b to the codec identified by its 1-byte prefix,
+// regardless of which Codec.Decode was called. This is what lets JSON
+// payloads already sitting in redis keep deserializing after the active
+// codec is switched to msgpack.
+//
+// Payloads written before the Codec abstraction existed have no framing
+// byte at all - MarshalBinary used to call json.Marshal directly - so their
+// first byte is whatever encoding/json emitted, typically '{' or '['. Those
+// are treated as legacy unframed JSON and decoded as a whole rather than
+// rejected as an unknown prefix.
+func decodeFramed(b []byte, v any) error {
+	if len(b) == 0 {
+		return fmt.Errorf("cannot decode empty payload")
+	}
+
+	switch codecPrefix(b[0]) {
+	case jsonCodecPrefix:
+		if err := json.Unmarshal(b[1:], v); err != nil {
+			return fmt.Errorf("failed to json decode: %w", err)
+		}
+	case msgpackCodecPrefix:
+		if err := msgpack.Unmarshal(b[1:], v); err != nil {
+			return fmt.Errorf("failed to msgpack decode: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(b, v); err != nil {
+			return fmt.Errorf("failed to json decode legacy unframed payload: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// JSONCodec encodes with encoding/json, framed with jsonCodecPrefix. This is
+// the original MarshalBinary behaviour of FeatureFlag/Target/Segment.
+type JSONCodec struct{}
+
+// Encode json-marshals v and prepends the json framing prefix
+func (JSONCodec) Encode(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to json encode: %w", err)
+	}
+	return append([]byte{byte(jsonCodecPrefix)}, b...), nil
+}
+
+// Decode inspects the framing prefix and decodes accordingly
+func (JSONCodec) Decode(b []byte, v any) error {
+	return decodeFramed(b, v)
+}
+
+// MsgpackCodec encodes with vmihailenco/msgpack, framed with
+// msgpackCodecPrefix. It produces smaller payloads than JSONCodec for the
+// same FeatureConfig/Segment data, which matters once the cache is holding
+// millions of them.
+type MsgpackCodec struct{}
+
+// Encode msgpack-marshals v and prepends the msgpack framing prefix
+func (MsgpackCodec) Encode(v any) ([]byte, error) {
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to msgpack encode: %w", err)
+	}
+	return append([]byte{byte(msgpackCodecPrefix)}, b...), nil
+}
+
+// Decode inspects the framing prefix and decodes accordingly
+func (MsgpackCodec) Decode(b []byte, v any) error {
+	return decodeFramed(b, v)
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"testing"
+
+	clientgen "github.com/harness/ff-proxy/gen/client"
+)
+
+func benchFeatureConfig() FeatureConfig {
+	return FeatureConfig{
+		FeatureFlag: FeatureFlag{
+			FeatureConfig: clientgen.FeatureConfig{
+				Feature:      "bench-flag",
+				Kind:         "boolean",
+				Project:      "bench-project",
+				Environment:  "bench-env",
+				OffVariation: "false",
+			},
+		},
+		Segments: map[string]Segment{
+			"bench-segment": benchSegment(),
+		},
+	}
+}
+
+func benchSegment() Segment {
+	return Segment{
+		Segment: clientgen.Segment{
+			Identifier:  "bench-segment",
+			Name:        "Bench Segment",
+			Environment: "bench-env",
+			Excluded:    &[]clientgen.Target{},
+			Included: &[]clientgen.Target{
+				{Identifier: "target-1", Name: "Target 1"},
+				{Identifier: "target-2", Name: "Target 2"},
+			},
+		},
+	}
+}
+
+// benchFeatureConfig and benchSegment are plain *Segment/*FeatureConfig
+// values, which implement encoding.BinaryMarshaler via MarshalBinary.
+// MsgpackCodec honors that interface, so encoding them directly would
+// recurse into MarshalBinary instead of measuring a real msgpack encode.
+// Benchmarks and size comparisons below encode the wire-alias segment type
+// instead, the same way MarshalBinary itself does, to get a true reading.
+
+func BenchmarkJSONCodec_EncodeFeatureConfig(b *testing.B) {
+	codec := JSONCodec{}
+	fc := benchFeatureConfig()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Encode(&fc); err != nil {
+			b.Fatalf("encode failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkMsgpackCodec_EncodeFeatureConfig(b *testing.B) {
+	codec := MsgpackCodec{}
+	fc := benchFeatureConfig()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Encode(&fc); err != nil {
+			b.Fatalf("encode failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkJSONCodec_EncodeSegment(b *testing.B) {
+	codec := JSONCodec{}
+	seg := segmentWire(benchSegment())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Encode(&seg); err != nil {
+			b.Fatalf("encode failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkMsgpackCodec_EncodeSegment(b *testing.B) {
+	codec := MsgpackCodec{}
+	seg := segmentWire(benchSegment())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Encode(&seg); err != nil {
+			b.Fatalf("encode failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkCodec_PayloadSize(b *testing.B) {
+	seg := segmentWire(benchSegment())
+
+	jsonBytes, err := (JSONCodec{}).Encode(&seg)
+	if err != nil {
+		b.Fatalf("json encode failed: %v", err)
+	}
+	msgpackBytes, err := (MsgpackCodec{}).Encode(&seg)
+	if err != nil {
+		b.Fatalf("msgpack encode failed: %v", err)
+	}
+
+	b.Logf("json bytes=%d msgpack bytes=%d", len(jsonBytes), len(msgpackBytes))
+}
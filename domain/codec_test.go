@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeFramed_LegacyUnframedJSON(t *testing.T) {
+	seg := benchSegment()
+	legacy, err := json.Marshal(&seg)
+	if err != nil {
+		t.Fatalf("failed to marshal fixture: %v", err)
+	}
+
+	var decoded Segment
+	err = (JSONCodec{}).Decode(legacy, &decoded)
+
+	assert.NoError(t, err)
+	assert.Equal(t, seg, decoded)
+}
+
+func TestJSONCodec_RoundTrip(t *testing.T) {
+	seg := benchSegment()
+	codec := JSONCodec{}
+
+	b, err := codec.Encode(&seg)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	var decoded Segment
+	if err := codec.Decode(b, &decoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	assert.Equal(t, seg, decoded)
+}
+
+func TestMsgpackCodec_RoundTrip(t *testing.T) {
+	seg := benchSegment()
+	codec := MsgpackCodec{}
+
+	b, err := codec.Encode(&seg)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	var decoded Segment
+	if err := codec.Decode(b, &decoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	assert.Equal(t, seg, decoded)
+}
+
+// TestSegment_MarshalBinary_ActiveCodecMsgpack exercises the real cache
+// write/read path (Segment.MarshalBinary/UnmarshalBinary) with MsgpackCodec
+// as the active codec. MsgpackCodec honors encoding.BinaryMarshaler, so if
+// MarshalBinary encoded *Segment directly instead of the method-less
+// segmentWire alias, this would recurse into MarshalBinary forever.
+func TestSegment_MarshalBinary_ActiveCodecMsgpack(t *testing.T) {
+	SetActiveCodec(MsgpackCodec{})
+	defer SetActiveCodec(JSONCodec{})
+
+	seg := benchSegment()
+
+	b, err := seg.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	var decoded Segment
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	assert.Equal(t, seg, decoded)
+}
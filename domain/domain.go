@@ -1,7 +1,6 @@
 package domain
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/dgrijalva/jwt-go"
@@ -29,16 +28,22 @@ type FeatureConfig struct {
 	Segments map[string]Segment `json:"segments"`
 }
 
-// MarshalBinary marshals a FeatureFlag to bytes. Currently it just uses json
-// marshaling but if we want to optimise storage space we could use something
-// more efficient
+// featureFlagWire is FeatureFlag stripped of its MarshalBinary/UnmarshalBinary
+// methods. MsgpackCodec honors encoding.BinaryMarshaler, so encoding a
+// *FeatureFlag directly would call back into FeatureFlag.MarshalBinary and
+// recurse forever once MsgpackCodec is the active codec - encoding the wire
+// type instead lets the codec see plain struct fields.
+type featureFlagWire FeatureFlag
+
+// MarshalBinary marshals a FeatureFlag to bytes using the active Codec
 func (f *FeatureFlag) MarshalBinary() ([]byte, error) {
-	return json.Marshal(f)
+	return activeCodec.Encode((*featureFlagWire)(f))
 }
 
-// UnmarshalBinary unmarshals bytes to a FeatureFlag
+// UnmarshalBinary unmarshals bytes to a FeatureFlag, detecting which Codec
+// encoded them from the framing prefix
 func (f *FeatureFlag) UnmarshalBinary(b []byte) error {
-	return json.Unmarshal(b, f)
+	return activeCodec.Decode(b, (*featureFlagWire)(f))
 }
 
 // TargetKey is the key that maps to a Target
@@ -54,15 +59,19 @@ type Target struct {
 	admingen.Target
 }
 
-// MarshalBinary marshals a Target to bytes. Currently it uses json marshaling
-// but if we want to optimise storage space we could use something more efficient
+// targetWire is Target stripped of its MarshalBinary/UnmarshalBinary methods,
+// for the same reason as featureFlagWire above
+type targetWire Target
+
+// MarshalBinary marshals a Target to bytes using the active Codec
 func (t *Target) MarshalBinary() ([]byte, error) {
-	return json.Marshal(t)
+	return activeCodec.Encode((*targetWire)(t))
 }
 
-// UnmarshalBinary unmarshals bytes to a Target
+// UnmarshalBinary unmarshals bytes to a Target, detecting which Codec
+// encoded them from the framing prefix
 func (t *Target) UnmarshalBinary(b []byte) error {
-	return json.Unmarshal(b, t)
+	return activeCodec.Decode(b, (*targetWire)(t))
 }
 
 // SegmentKey is the key that maps to a Segment
@@ -78,22 +87,83 @@ type Segment struct {
 	clientgen.Segment
 }
 
-// MarshalBinary marshals a Segment to bytes. Currently it uses json marshaling
-// but if we want to optimise storage space we could use something more efficient
+// segmentWire is Segment stripped of its MarshalBinary/UnmarshalBinary
+// methods, for the same reason as featureFlagWire above
+type segmentWire Segment
+
+// MarshalBinary marshals a Segment to bytes using the active Codec
 func (s *Segment) MarshalBinary() ([]byte, error) {
-	return json.Marshal(s)
+	return activeCodec.Encode((*segmentWire)(s))
 }
 
-// UnmarshalBinary unmarshals bytes to a Segment
+// UnmarshalBinary unmarshals bytes to a Segment, detecting which Codec
+// encoded them from the framing prefix
 func (s *Segment) UnmarshalBinary(b []byte) error {
-	return json.Unmarshal(b, s)
+	return activeCodec.Decode(b, (*segmentWire)(s))
+}
+
+// MetricsRequest is the metrics data we've aggregated for a single
+// environment, ready to be flushed to wherever metrics get sent
+type MetricsRequest struct {
+	EnvironmentID string
+	clientgen.Metrics
 }
 
 // AuthAPIKey is the APIKey type used for authentication lookups
 type AuthAPIKey string
 
+// EnvironmentID is the type used to identify an environment in auth/config data
+type EnvironmentID string
+
+// AuthConfig is the data we store against an AuthAPIKey so it can be looked
+// up by the proxy's auth middleware
+type AuthConfig struct {
+	APIKey        AuthAPIKey
+	EnvironmentID EnvironmentID
+	// OrgID and ProjectID are optional and default to "" for proxy instances
+	// that don't use the admin-partition / org model
+	OrgID     string
+	ProjectID string
+}
+
+// AuthScope identifies the admin-partition / org scope that an AuthAPIKey's
+// EnvironmentID belongs to. OrgID/ProjectID are "" for keys that predate the
+// org model, in which case an AuthScope is equivalent to its bare EnvID.
+type AuthScope struct {
+	OrgID     string
+	ProjectID string
+	EnvID     string
+}
+
+// String renders scope in the "org/project/env" form that ApprovedScopes
+// glob patterns are matched against. A scope with no OrgID/ProjectID renders
+// as its bare EnvID, so pre-org approved-env patterns keep working.
+func (s AuthScope) String() string {
+	if s.OrgID == "" && s.ProjectID == "" {
+		return s.EnvID
+	}
+	return fmt.Sprintf("%s/%s/%s", s.OrgID, s.ProjectID, s.EnvID)
+}
+
 // Claims are custom jwt claims used by the proxy for generating a jwt token
 type Claims struct {
 	Environment string `json:"environment"`
+	// OrgID and ProjectID are embedded so downstream handlers can restrict
+	// /client/env/{env} routes to the token's admin-partition scope
+	OrgID     string `json:"org,omitempty"`
+	ProjectID string `json:"project,omitempty"`
+	// Jti is a unique identifier for the token, it lets us revoke a single
+	// minted token without having to revoke the API key it was minted from
+	Jti string `json:"jti"`
 	jwt.StandardClaims
 }
+
+// RevocationRecord is the data we persist in a RevocationStore when an
+// AuthAPIKey or a single jwt is revoked. ExpiresAt lets the store evict the
+// record once the token it refers to would've expired naturally anyway
+type RevocationRecord struct {
+	// Jti is empty when the record represents a whole API key being revoked
+	Jti       string
+	RevokedAt int64
+	ExpiresAt int64
+}
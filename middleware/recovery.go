@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/harness/ff-proxy/log"
+)
+
+var panicTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ff_proxy_panic_total",
+	Help: "Total number of panics recovered from proxy handlers",
+}, []string{"handler"})
+
+// errorResponse is the stable JSON body returned to the client when a
+// handler panics
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewRecovery wraps next so a panic anywhere inside it is recovered, logged
+// with its stack trace via logger, counted in ff_proxy_panic_total{handler},
+// and turned into a stable 500 JSON response instead of taking down the
+// request's goroutine and dropping the SDK's connection.
+func NewRecovery(logger log.StructuredLogger, handlerName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					panicTotal.WithLabelValues(handlerName).Inc()
+					logger.Error(fmt.Sprintf("recovered from panic in handler %q: %v\n%s", handlerName, rec, debug.Stack()))
+
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusInternalServerError)
+					_ = json.NewEncoder(w).Encode(errorResponse{
+						Code:    "INTERNAL_ERROR",
+						Message: "an unexpected error occurred",
+					})
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
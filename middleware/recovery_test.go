@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harness/ff-proxy/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery_RecoversPanic(t *testing.T) {
+	logger, _ := log.NewStructuredLogger(true)
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	handler := NewRecovery(logger, "test-handler")(panicking)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	assert.NotPanics(t, func() {
+		handler.ServeHTTP(rec, req)
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"code":"INTERNAL_ERROR","message":"an unexpected error occurred"}`, rec.Body.String())
+}
+
+func TestRecovery_PassesThroughWithoutPanic(t *testing.T) {
+	logger, _ := log.NewStructuredLogger(true)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := NewRecovery(logger, "test-handler")(ok)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
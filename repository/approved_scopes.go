@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"path"
+
+	"github.com/harness/ff-proxy/domain"
+)
+
+// ApprovedScopes is a set of glob patterns (e.g. "org-a/*/env-*") that
+// AuthScopes are matched against to decide whether a proxy instance serves
+// them. An empty ApprovedScopes allows every scope, preserving the
+// pre-admin-partition behaviour of an unset approved env list.
+type ApprovedScopes []string
+
+// Matches reports whether scope matches any of the configured patterns
+func (a ApprovedScopes) Matches(scope domain.AuthScope) bool {
+	if len(a) == 0 {
+		return true
+	}
+
+	for _, pattern := range a {
+		if ok, err := path.Match(pattern, scope.String()); ok && err == nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/harness/ff-proxy/cache"
+	"github.com/harness/ff-proxy/domain"
+)
+
+// authRepoCacheKey is the cache key that the auth map is stored under
+const authRepoCacheKey = "auth-config"
+
+// AuthRepo is a repository that stores a map of AuthAPIKey to the
+// EnvironmentID it authenticates for, filtered by ApprovedScopes
+type AuthRepo struct {
+	cache           cache.Cache
+	approvedScopes  ApprovedScopes
+	revocationStore RevocationStore
+
+	scopesLock *sync.RWMutex
+	scopes     map[domain.AuthAPIKey]domain.AuthScope
+}
+
+// AuthRepoOption configures optional AuthRepo behaviour
+type AuthRepoOption func(*AuthRepo)
+
+// WithRevocationStore overrides the default MemRevocationStore, e.g. with a
+// RedisRevocationStore so a Revoke on one replica is immediately visible to
+// every other replica behind the proxy, and survives restarts
+func WithRevocationStore(store RevocationStore) AuthRepoOption {
+	return func(a *AuthRepo) { a.revocationStore = store }
+}
+
+// newAuthRepo builds an empty AuthRepo filtering reads by approvedScopes. An
+// empty approvedScopes allows all scopes, matching the pre-admin-partition
+// behaviour of an unset approved env list.
+func newAuthRepo(c cache.Cache, approvedScopes ApprovedScopes, opts ...AuthRepoOption) AuthRepo {
+	repo := AuthRepo{
+		cache:           c,
+		approvedScopes:  approvedScopes,
+		revocationStore: NewMemRevocationStore(),
+		scopesLock:      &sync.RWMutex{},
+		scopes:          map[domain.AuthAPIKey]domain.AuthScope{},
+	}
+
+	for _, opt := range opts {
+		opt(&repo)
+	}
+
+	return repo
+}
+
+// NewAuthRepo creates an AuthRepo, populating it with the passed data and
+// filtering reads by approvedScopes. data only carries an EnvironmentID per
+// key, so every key it populates gets an AuthScope with empty OrgID/ProjectID
+// - fine for approvedScopes patterns matched against a bare env, but an
+// org-scoped pattern like "org-a/*/env-*" will never match them. Proxy
+// instances that need org/project scoping should populate via
+// NewAuthRepoFromConfigs instead.
+//
+// Any keys already in the cache for an environment that data populates are
+// removed first, so data replaces that environment's keys rather than adding
+// to them; keys for other environments are left alone.
+//
+// By default revocations are only tracked in-memory; pass WithRevocationStore
+// to share them across replicas and restarts, and see StartRevocationSweeper
+// to keep that store bounded.
+func NewAuthRepo(c cache.Cache, data map[domain.AuthAPIKey]string, approvedScopes ApprovedScopes, opts ...AuthRepoOption) (AuthRepo, error) {
+	repo := newAuthRepo(c, approvedScopes, opts...)
+
+	envs := map[string]struct{}{}
+	for _, env := range data {
+		envs[env] = struct{}{}
+	}
+	existing := map[string]string{}
+	if err := repo.cache.GetAll(context.Background(), authRepoCacheKey, &existing); err == nil {
+		for key, env := range existing {
+			if _, ok := envs[env]; !ok {
+				continue
+			}
+			if err := repo.cache.Remove(context.Background(), authRepoCacheKey, key); err != nil {
+				return AuthRepo{}, fmt.Errorf("failed to clear old auth keys: %w", err)
+			}
+		}
+	}
+
+	for key, env := range data {
+		if err := repo.Add(context.Background(), domain.AuthConfig{APIKey: key, EnvironmentID: domain.EnvironmentID(env)}); err != nil {
+			return AuthRepo{}, fmt.Errorf("failed to populate AuthRepo: %w", err)
+		}
+	}
+
+	return repo, nil
+}
+
+// NewAuthRepoFromConfigs creates an AuthRepo from a slice of full
+// domain.AuthConfig entries, so keys carry their OrgID/ProjectID as well as
+// their EnvironmentID. This is the path that makes org-scoped ApprovedScopes
+// patterns (e.g. "org-a/*/env-*") usable - NewAuthRepo's flat env map can't
+// express the org/project half of the scope.
+func NewAuthRepoFromConfigs(c cache.Cache, configs []domain.AuthConfig, approvedScopes ApprovedScopes, opts ...AuthRepoOption) (AuthRepo, error) {
+	repo := newAuthRepo(c, approvedScopes, opts...)
+
+	for _, config := range configs {
+		if err := repo.Add(context.Background(), config); err != nil {
+			return AuthRepo{}, fmt.Errorf("failed to populate AuthRepo: %w", err)
+		}
+	}
+
+	return repo, nil
+}
+
+// StartRevocationSweeper runs a RevocationSweeper against this AuthRepo's
+// RevocationStore every interval, to keep it bounded. It blocks until ctx is
+// cancelled, so callers should run it in its own goroutine, e.g.
+// `go authRepo.StartRevocationSweeper(ctx, time.Hour)`.
+func (a AuthRepo) StartRevocationSweeper(ctx context.Context, interval time.Duration) {
+	NewRevocationSweeper(a.revocationStore, interval).Start(ctx)
+}
+
+// scopeFor builds the AuthScope for key/env, preferring the full scope
+// recorded by Add if one exists so callers get OrgID/ProjectID as well
+func (a AuthRepo) scopeFor(key domain.AuthAPIKey, env string) domain.AuthScope {
+	a.scopesLock.RLock()
+	defer a.scopesLock.RUnlock()
+
+	if scope, ok := a.scopes[key]; ok {
+		return scope
+	}
+	return domain.AuthScope{EnvID: env}
+}
+
+// Get retrieves the EnvironmentID that key authenticates for, returning
+// false if the key doesn't exist, has been revoked, its scope isn't
+// approved, or the revocation check itself failed - an unreachable
+// RevocationStore must fail closed rather than treat key as unrevoked
+func (a AuthRepo) Get(ctx context.Context, key domain.AuthAPIKey) (string, bool) {
+	if _, revoked, err := a.revocationStore.IsRevoked(ctx, HashAPIKey(key)); err != nil || revoked {
+		return "", false
+	}
+
+	var env string
+	if err := a.cache.Get(ctx, authRepoCacheKey, string(key), &env); err != nil {
+		return "", false
+	}
+
+	if !a.approvedScopes.Matches(a.scopeFor(key, env)) {
+		return "", false
+	}
+
+	return env, true
+}
+
+// getAll retrieves every AuthAPIKey to EnvironmentID mapping whose scope is
+// approved and hasn't been revoked. A key is dropped if the revocation check
+// itself errors - an unreachable RevocationStore must fail closed rather
+// than treat the key as unrevoked
+func (a AuthRepo) getAll(ctx context.Context) (map[domain.AuthAPIKey]string, bool) {
+	all := map[string]string{}
+	if err := a.cache.GetAll(ctx, authRepoCacheKey, &all); err != nil {
+		return map[domain.AuthAPIKey]string{}, false
+	}
+
+	result := map[domain.AuthAPIKey]string{}
+	for key, env := range all {
+		apiKey := domain.AuthAPIKey(key)
+		if !a.approvedScopes.Matches(a.scopeFor(apiKey, env)) {
+			continue
+		}
+		if _, revoked, err := a.revocationStore.IsRevoked(ctx, HashAPIKey(apiKey)); err != nil || revoked {
+			continue
+		}
+		result[apiKey] = env
+	}
+
+	return result, len(result) > 0
+}
+
+// Add stores a new APIKey -> EnvironmentID mapping, along with its
+// admin-partition scope
+func (a AuthRepo) Add(ctx context.Context, config domain.AuthConfig) error {
+	if err := a.cache.Set(ctx, authRepoCacheKey, string(config.APIKey), string(config.EnvironmentID)); err != nil {
+		return fmt.Errorf("failed to add auth config: %w", err)
+	}
+
+	a.scopesLock.Lock()
+	a.scopes[config.APIKey] = domain.AuthScope{
+		OrgID:     config.OrgID,
+		ProjectID: config.ProjectID,
+		EnvID:     string(config.EnvironmentID),
+	}
+	a.scopesLock.Unlock()
+
+	return nil
+}
+
+// ScopesFor returns the AuthScope that apiKey belongs to, so downstream
+// handlers and the jwt minter can embed the org/project in the token and
+// restrict /client/env/{env} routes to the token's scope
+func (a AuthRepo) ScopesFor(ctx context.Context, apiKey domain.AuthAPIKey) (domain.AuthScope, bool) {
+	a.scopesLock.RLock()
+	defer a.scopesLock.RUnlock()
+
+	scope, ok := a.scopes[apiKey]
+	return scope, ok
+}
+
+// Revoke immediately invalidates key: it's removed from the cache-backed
+// map and a revocation record is pushed to the RevocationStore so any jwt
+// already minted from it is rejected by the auth middleware until it would
+// have expired naturally anyway.
+func (a AuthRepo) Revoke(ctx context.Context, key domain.AuthAPIKey) error {
+	if err := a.cache.Remove(ctx, authRepoCacheKey, string(key)); err != nil {
+		return fmt.Errorf("failed to remove revoked key from cache: %w", err)
+	}
+
+	a.scopesLock.Lock()
+	delete(a.scopes, key)
+	a.scopesLock.Unlock()
+
+	now := time.Now()
+	record := domain.RevocationRecord{
+		RevokedAt: now.Unix(),
+		// StandardClaims carries a 24h expiry for minted jwts, so bound the
+		// revocation record to the same horizon rather than keeping it
+		// forever
+		ExpiresAt: now.Add(24 * time.Hour).Unix(),
+	}
+
+	if err := a.revocationStore.Revoke(ctx, HashAPIKey(key), record); err != nil {
+		return fmt.Errorf("failed to revoke auth api key: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeToken revokes a single jwt by its jti, without invalidating the
+// API key it was minted from
+func (a AuthRepo) RevokeToken(ctx context.Context, jti string, exp int64) error {
+	record := domain.RevocationRecord{
+		Jti:       jti,
+		RevokedAt: time.Now().Unix(),
+		ExpiresAt: exp,
+	}
+
+	if err := a.revocationStore.Revoke(ctx, jti, record); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+// IsTokenRevoked reports whether a token issued at iat with the given jti
+// should be rejected, either because the jti itself was revoked or because
+// the source API key was revoked after the token was issued. If the
+// RevocationStore can't be reached to answer either check, the token is
+// treated as revoked - this is the one path that must fail closed rather
+// than let an unreachable store wave through a leaked or revoked token.
+func (a AuthRepo) IsTokenRevoked(ctx context.Context, apiKey domain.AuthAPIKey, jti string, iat int64) bool {
+	if _, revoked, err := a.revocationStore.IsRevoked(ctx, jti); err != nil || revoked {
+		return true
+	}
+
+	record, revoked, err := a.revocationStore.IsRevoked(ctx, HashAPIKey(apiKey))
+	if err != nil {
+		return true
+	}
+	if revoked {
+		return record.RevokedAt >= iat
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/harness/ff-proxy/cache"
+	"github.com/harness/ff-proxy/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthRepo_ScopesFor(t *testing.T) {
+	repo, err := NewAuthRepo(cache.NewMemCache(), map[domain.AuthAPIKey]string{}, emptyApprovedScopes)
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	err = repo.Add(context.Background(), domain.AuthConfig{
+		APIKey:        "apikey-foo",
+		EnvironmentID: "env-foo",
+		OrgID:         "org-a",
+		ProjectID:     "project-1",
+	})
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	scope, ok := repo.ScopesFor(context.Background(), "apikey-foo")
+	assert.True(t, ok)
+	assert.Equal(t, domain.AuthScope{OrgID: "org-a", ProjectID: "project-1", EnvID: "env-foo"}, scope)
+
+	_, ok = repo.ScopesFor(context.Background(), "apikey-missing")
+	assert.False(t, ok)
+}
+
+func TestAuthRepo_WithRevocationStore(t *testing.T) {
+	store := NewMemRevocationStore()
+
+	repo, err := NewAuthRepo(cache.NewMemCache(), map[domain.AuthAPIKey]string{
+		"apikey-foo": "env-foo",
+	}, emptyApprovedScopes, WithRevocationStore(store))
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	if err := repo.Revoke(context.Background(), "apikey-foo"); err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	_, revoked, err := store.IsRevoked(context.Background(), HashAPIKey("apikey-foo"))
+	assert.NoError(t, err)
+	assert.True(t, revoked, "expected Revoke to persist to the injected RevocationStore")
+}
+
+// errRevocationStore is a RevocationStore whose IsRevoked always errors, to
+// exercise the auth checks' fail-closed behaviour when the store can't be
+// reached
+type errRevocationStore struct {
+	RevocationStore
+}
+
+func (errRevocationStore) IsRevoked(ctx context.Context, keyHash string) (domain.RevocationRecord, bool, error) {
+	return domain.RevocationRecord{}, false, errors.New("store unreachable")
+}
+
+func TestAuthRepo_RevocationStoreError_FailsClosed(t *testing.T) {
+	repo, err := NewAuthRepo(cache.NewMemCache(), map[domain.AuthAPIKey]string{
+		"apikey-foo": "env-foo",
+	}, emptyApprovedScopes, WithRevocationStore(errRevocationStore{}))
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	_, ok := repo.Get(context.Background(), "apikey-foo")
+	assert.False(t, ok, "Get should deny when the revocation check errors")
+
+	all, ok := repo.getAll(context.Background())
+	assert.False(t, ok)
+	assert.Empty(t, all, "getAll should drop keys whose revocation check errors")
+
+	revoked := repo.IsTokenRevoked(context.Background(), "apikey-foo", "jti-1", time.Now().Unix())
+	assert.True(t, revoked, "IsTokenRevoked should treat an unreachable store as revoked")
+}
+
+func TestNewAuthRepoFromConfigs_OrgScopedApprovedScopes(t *testing.T) {
+	configs := []domain.AuthConfig{
+		{APIKey: "apikey-foo", EnvironmentID: "env-foo", OrgID: "org-a", ProjectID: "project-1"},
+		{APIKey: "apikey-bar", EnvironmentID: "env-bar", OrgID: "org-b", ProjectID: "project-1"},
+	}
+
+	repo, err := NewAuthRepoFromConfigs(cache.NewMemCache(), configs, ApprovedScopes{"org-a/*/env-*"})
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	env, ok := repo.Get(context.Background(), "apikey-foo")
+	assert.True(t, ok)
+	assert.Equal(t, "env-foo", env)
+
+	_, ok = repo.Get(context.Background(), "apikey-bar")
+	assert.False(t, ok)
+
+	all, ok := repo.getAll(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, map[domain.AuthAPIKey]string{"apikey-foo": "env-foo"}, all)
+}
@@ -11,7 +11,7 @@ import (
 )
 
 var (
-	emptyApprovedEnvironments = map[string]struct{}{}
+	emptyApprovedScopes = ApprovedScopes{}
 )
 
 func TestAuthRepo_Get(t *testing.T) {
@@ -27,53 +27,60 @@ func TestAuthRepo_Get(t *testing.T) {
 	}
 
 	testCases := map[string]struct {
-		cache        cache.Cache
-		data         map[domain.AuthAPIKey]string
-		approvedEnvs map[string]struct{}
-		key          string
-		expected     expected
+		cache          cache.Cache
+		data           map[domain.AuthAPIKey]string
+		approvedScopes ApprovedScopes
+		key            string
+		expected       expected
 	}{
 		"Given I have an empty AuthRepo": {
-			cache:        cache.NewMemCache(),
-			data:         unpopulated,
-			approvedEnvs: emptyApprovedEnvironments,
-			key:          "apikey-foo",
-			expected:     expected{strVal: "", boolVal: false},
+			cache:          cache.NewMemCache(),
+			data:           unpopulated,
+			approvedScopes: emptyApprovedScopes,
+			key:            "apikey-foo",
+			expected:       expected{strVal: "", boolVal: false},
 		},
 		"Given I have a populated AuthRepo but try to get a key that doesn't exist": {
-			cache:        cache.NewMemCache(),
-			data:         populated,
-			approvedEnvs: emptyApprovedEnvironments,
-			key:          "foo",
-			expected:     expected{strVal: "", boolVal: false},
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: emptyApprovedScopes,
+			key:            "foo",
+			expected:       expected{strVal: "", boolVal: false},
 		},
 		"Given I have a populated AuthRepo and try to get a key that does exist": {
-			cache:        cache.NewMemCache(),
-			data:         populated,
-			approvedEnvs: emptyApprovedEnvironments,
-			key:          "apikey-foo",
-			expected:     expected{strVal: "env-approved", boolVal: true},
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: emptyApprovedScopes,
+			key:            "apikey-foo",
+			expected:       expected{strVal: "env-approved", boolVal: true},
 		},
-		"Given I have a populated AuthRepo and try to get a key that is on the approved env list": {
-			cache:        cache.NewMemCache(),
-			data:         populated,
-			approvedEnvs: map[string]struct{}{"env-approved": struct{}{}},
-			key:          "apikey-foo",
-			expected:     expected{strVal: "env-approved", boolVal: true},
+		"Given I have a populated AuthRepo and try to get a key that is on the approved scope list": {
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: ApprovedScopes{"env-approved"},
+			key:            "apikey-foo",
+			expected:       expected{strVal: "env-approved", boolVal: true},
 		},
-		"Given I have a populated AuthRepo and try to get a key that isn't on the approved env list": {
-			cache:        cache.NewMemCache(),
-			data:         populated,
-			approvedEnvs: map[string]struct{}{"env-approved": struct{}{}},
-			key:          "apikey-2",
-			expected:     expected{strVal: "", boolVal: false},
+		"Given I have a populated AuthRepo and try to get a key that isn't on the approved scope list": {
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: ApprovedScopes{"env-approved"},
+			key:            "apikey-2",
+			expected:       expected{strVal: "", boolVal: false},
+		},
+		"Given I have a populated AuthRepo and try to get a key matching a glob scope pattern": {
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: ApprovedScopes{"env-*"},
+			key:            "apikey-foo",
+			expected:       expected{strVal: "env-approved", boolVal: true},
 		},
 	}
 	for desc, tc := range testCases {
 		tc := tc
 		t.Run(desc, func(t *testing.T) {
 
-			repo, err := NewAuthRepo(tc.cache, tc.data, tc.approvedEnvs)
+			repo, err := NewAuthRepo(tc.cache, tc.data, tc.approvedScopes)
 			if err != nil {
 				t.Fatalf("(%s): error = %v", desc, err)
 			}
@@ -103,12 +110,12 @@ func TestAuthRepo_GetAll(t *testing.T) {
 	}
 
 	testCases := map[string]struct {
-		cache        cache.Cache
-		data         map[domain.AuthAPIKey]string
-		approvedEnvs map[string]struct{}
-		fn           func(repo AuthRepo)
-		key          string
-		expected     expected
+		cache          cache.Cache
+		data           map[domain.AuthAPIKey]string
+		approvedScopes ApprovedScopes
+		fn             func(repo AuthRepo)
+		key            string
+		expected       expected
 	}{
 		"Given I have an empty AuthRepo": {
 			cache:    cache.NewMemCache(),
@@ -120,25 +127,31 @@ func TestAuthRepo_GetAll(t *testing.T) {
 			data:     populated,
 			expected: expected{keys: populated, boolVal: true},
 		},
-		"Given I have a populated AuthRepo and approved env list with all envs": {
-			cache:        cache.NewMemCache(),
-			data:         populated,
-			approvedEnvs: map[string]struct{}{"env-foo": struct{}{}, "env-bar": struct{}{}},
-			expected:     expected{keys: populated, boolVal: true},
+		"Given I have a populated AuthRepo and approved scope list with all envs": {
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: ApprovedScopes{"env-foo", "env-bar"},
+			expected:       expected{keys: populated, boolVal: true},
 		},
-		"Given I have a populated AuthRepo and approved env list with one env": {
-			cache:        cache.NewMemCache(),
-			data:         populated,
-			approvedEnvs: map[string]struct{}{"env-foo": struct{}{}},
+		"Given I have a populated AuthRepo and approved scope list with one env": {
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: ApprovedScopes{"env-foo"},
 			expected: expected{keys: map[domain.AuthAPIKey]string{
 				domain.AuthAPIKey("apikey-foo"): "env-foo",
 			}, boolVal: true},
 		},
-		"Given I have a populated AuthRepo and approved env list with env with no results": {
-			cache:        cache.NewMemCache(),
-			data:         populated,
-			approvedEnvs: map[string]struct{}{"env-noexist": struct{}{}},
-			expected:     expected{keys: map[domain.AuthAPIKey]string{}, boolVal: false},
+		"Given I have a populated AuthRepo and approved scope list with env with no results": {
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: ApprovedScopes{"env-noexist"},
+			expected:       expected{keys: map[domain.AuthAPIKey]string{}, boolVal: false},
+		},
+		"Given I have a populated AuthRepo and a glob approved scope list": {
+			cache:          cache.NewMemCache(),
+			data:           populated,
+			approvedScopes: ApprovedScopes{"env-*"},
+			expected:       expected{keys: populated, boolVal: true},
 		},
 		"Given I add to the  AuthRepo": {
 			cache: cache.NewMemCache(),
@@ -159,7 +172,7 @@ func TestAuthRepo_GetAll(t *testing.T) {
 		tc := tc
 		t.Run(desc, func(t *testing.T) {
 
-			repo, err := NewAuthRepo(tc.cache, tc.data, tc.approvedEnvs)
+			repo, err := NewAuthRepo(tc.cache, tc.data, tc.approvedScopes)
 			if err != nil {
 				t.Fatalf("(%s): error = %v", desc, err)
 			}
@@ -227,13 +240,13 @@ func TestAuthRepo_Setup(t *testing.T) {
 		t.Run(desc, func(t *testing.T) {
 
 			// populate initial data
-			_, err := NewAuthRepo(tc.cache, tc.initialData, emptyApprovedEnvironments)
+			_, err := NewAuthRepo(tc.cache, tc.initialData, emptyApprovedScopes)
 			if err != nil {
 				t.Fatalf("(%s): error = %v", desc, err)
 			}
 
 			// populate extra data
-			repo, err := NewAuthRepo(tc.cache, tc.extraData, emptyApprovedEnvironments)
+			repo, err := NewAuthRepo(tc.cache, tc.extraData, emptyApprovedScopes)
 			if err != nil {
 				t.Fatalf("(%s): error = %v", desc, err)
 			}
@@ -245,6 +258,46 @@ func TestAuthRepo_Setup(t *testing.T) {
 	}
 }
 
+func TestApprovedScopes_Matches(t *testing.T) {
+	testCases := map[string]struct {
+		approvedScopes ApprovedScopes
+		scope          domain.AuthScope
+		expected       bool
+	}{
+		"Given an empty ApprovedScopes everything matches (backward-compat)": {
+			approvedScopes: ApprovedScopes{},
+			scope:          domain.AuthScope{EnvID: "env-foo"},
+			expected:       true,
+		},
+		"Given a bare env pattern it matches a scope with no org/project": {
+			approvedScopes: ApprovedScopes{"env-foo"},
+			scope:          domain.AuthScope{EnvID: "env-foo"},
+			expected:       true,
+		},
+		"Given a glob org/project/env pattern it matches": {
+			approvedScopes: ApprovedScopes{"org-a/*/env-*"},
+			scope:          domain.AuthScope{OrgID: "org-a", ProjectID: "project-1", EnvID: "env-foo"},
+			expected:       true,
+		},
+		"Given a glob pattern for a different org it doesn't match": {
+			approvedScopes: ApprovedScopes{"org-a/*/env-*"},
+			scope:          domain.AuthScope{OrgID: "org-b", ProjectID: "project-1", EnvID: "env-foo"},
+			expected:       false,
+		},
+		"Given a scope with an org/project but only a bare env pattern it doesn't match": {
+			approvedScopes: ApprovedScopes{"env-foo"},
+			scope:          domain.AuthScope{OrgID: "org-a", ProjectID: "project-1", EnvID: "env-foo"},
+			expected:       false,
+		},
+	}
+
+	for desc, tc := range testCases {
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.approvedScopes.Matches(tc.scope))
+		})
+	}
+}
+
 // merge any number of auth maps into one
 // used to produce expected test results easier
 func mergeAuthMaps(maps ...map[domain.AuthAPIKey]string) map[domain.AuthAPIKey]string {
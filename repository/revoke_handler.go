@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/harness/ff-proxy/domain"
+)
+
+// revokeRequest is the body expected by NewRevokeHandler
+type revokeRequest struct {
+	APIKey domain.AuthAPIKey `json:"apiKey"`
+}
+
+// NewRevokeHandler returns an admin HTTP handler that revokes the API key
+// in the request body, so operators can kill a leaked SDK key without
+// restarting the proxy
+func NewRevokeHandler(repo AuthRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req revokeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.APIKey == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := repo.Revoke(r.Context(), req.APIKey); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/harness/ff-proxy/domain"
+	clientgen "github.com/harness/ff-proxy/gen/client"
+	"github.com/harness/ff-proxy/log"
+)
+
+const (
+	// defaultMaxMetricsDataPerEnv bounds how many MetricsData entries we'll
+	// buffer for a single environment between flushes
+	defaultMaxMetricsDataPerEnv = 10_000
+	// defaultMaxTargetDataPerEnv bounds how many TargetData entries we'll
+	// buffer for a single environment between flushes
+	defaultMaxTargetDataPerEnv = 10_000
+	// maxSendAttempts is how many times we'll try to send a batch, counting
+	// the first send, before dropping it
+	maxSendAttempts = 5
+
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// tokenContextKey is the type used for the context key that carries the
+// bearer token used to authenticate with the metrics endpoint
+type tokenContextKey string
+
+// tokenKey is the context key that addAuthToken reads the bearer token from
+const tokenKey tokenContextKey = "token"
+
+var metricsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ff_proxy_metrics_dropped_total",
+	Help: "Total number of metrics batches dropped without being sent",
+}, []string{"env", "reason"})
+
+// metricsClient is the subset of the generated Harness SaaS client that
+// HarnessSink depends on, so it can be mocked in tests
+type metricsClient interface {
+	PostMetricsWithResponse(ctx context.Context, environment clientgen.EnvironmentPathParam, params *clientgen.PostMetricsParams, body clientgen.PostMetricsJSONRequestBody, reqEditors ...clientgen.RequestEditorFn) (*clientgen.PostMetricsResponse, error)
+}
+
+// retryBatch is a metrics batch that failed to send and is waiting to be
+// retried with backoff
+type retryBatch struct {
+	env         string
+	metrics     domain.MetricsRequest
+	attempt     int
+	nextAttempt time.Time
+}
+
+// MetricService aggregates metrics per environment and periodically flushes
+// them to its configured MetricsSinks (the Harness SaaS endpoint by default,
+// plus any of OTLP/Prometheus). It bounds how much data it'll buffer per
+// environment and retries failed sends to the Harness sink with backoff
+// before giving up and dropping the batch.
+type MetricService struct {
+	logger  log.StructuredLogger
+	harness *HarnessSink
+	sinks   []MetricsSink
+	account string
+	tokens  map[string]string
+	enabled bool
+
+	lock    sync.Mutex
+	metrics map[string]domain.MetricsRequest
+
+	maxMetricsDataPerEnv int
+	maxTargetDataPerEnv  int
+
+	retryLock  sync.Mutex
+	retryQueue []retryBatch
+}
+
+// MetricServiceOption configures optional MetricService behaviour
+type MetricServiceOption func(*MetricService)
+
+// WithMaxMetricsDataPerEnv overrides the default MetricsData buffer bound
+func WithMaxMetricsDataPerEnv(n int) MetricServiceOption {
+	return func(m *MetricService) { m.maxMetricsDataPerEnv = n }
+}
+
+// WithMaxTargetDataPerEnv overrides the default TargetData buffer bound
+func WithMaxTargetDataPerEnv(n int) MetricServiceOption {
+	return func(m *MetricService) { m.maxTargetDataPerEnv = n }
+}
+
+// WithSinks registers additional MetricsSinks (e.g. an OTLPSink or
+// PrometheusSink) that every batch is flushed to alongside the Harness sink
+func WithSinks(sinks ...MetricsSink) MetricServiceOption {
+	return func(m *MetricService) { m.sinks = append(m.sinks, sinks...) }
+}
+
+// WithHarnessDisabled turns off delivery to the Harness SaaS metrics
+// endpoint entirely, for air-gapped deployments that only want the sinks
+// registered via WithSinks (e.g. OTLP/Prometheus). Use alongside
+// BuildExtraSinks/HasSink to drive this from the METRICS_SINKS env var.
+func WithHarnessDisabled() MetricServiceOption {
+	return func(m *MetricService) { m.harness = nil }
+}
+
+// NewMetricService creates a MetricService that sends metrics to metricsURL
+// using the passed per-environment tokens. Additional sinks (OTLP,
+// Prometheus) can be attached with WithSinks, e.g. built from the
+// METRICS_SINKS env var via ParseSinkNames.
+func NewMetricService(logger log.StructuredLogger, metricsURL string, account string, tokens map[string]string, enabled bool, opts ...MetricServiceOption) (*MetricService, error) {
+	client, err := clientgen.NewClientWithResponses(metricsURL, clientgen.WithRequestEditorFn(addAuthToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics client: %w", err)
+	}
+
+	m := &MetricService{
+		logger:               logger,
+		harness:              NewHarnessSink(client, tokens),
+		account:              account,
+		tokens:               tokens,
+		enabled:              enabled,
+		metrics:              map[string]domain.MetricsRequest{},
+		maxMetricsDataPerEnv: defaultMaxMetricsDataPerEnv,
+		maxTargetDataPerEnv:  defaultMaxTargetDataPerEnv,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m, nil
+}
+
+// StoreMetrics aggregates metrics for the environment they belong to.
+// TargetData is deduplicated by Identifier, and MetricsData entries that
+// share the same featureIdentifier/variationIdentifier/target/timestamp
+// bucket are collapsed into a single entry with an incremented Count.
+// Buffers are bounded per environment: once full, the oldest entries are
+// evicted and ff_proxy_metrics_dropped_total{reason="overflow"} is bumped.
+func (m *MetricService) StoreMetrics(ctx context.Context, metrics domain.MetricsRequest) {
+	if !m.enabled {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	existing, ok := m.metrics[metrics.EnvironmentID]
+	if !ok {
+		existing = domain.MetricsRequest{
+			EnvironmentID: metrics.EnvironmentID,
+			Metrics: clientgen.Metrics{
+				TargetData:  &[]clientgen.TargetData{},
+				MetricsData: &[]clientgen.MetricsData{},
+			},
+		}
+	}
+
+	mergedTargets := dedupeTargetData(existing.TargetData, metrics.TargetData)
+	mergedMetrics := mergeMetricsData(existing.MetricsData, metrics.MetricsData)
+
+	// A zero bound means "unbounded" - e.g. a MetricService built without
+	// going through NewMetricService, as some older callers and tests do.
+	if m.maxTargetDataPerEnv > 0 {
+		if dropped := len(mergedTargets) - m.maxTargetDataPerEnv; dropped > 0 {
+			mergedTargets = mergedTargets[dropped:]
+			metricsDropped.WithLabelValues(metrics.EnvironmentID, "overflow").Add(float64(dropped))
+		}
+	}
+	if m.maxMetricsDataPerEnv > 0 {
+		if dropped := len(mergedMetrics) - m.maxMetricsDataPerEnv; dropped > 0 {
+			mergedMetrics = mergedMetrics[dropped:]
+			metricsDropped.WithLabelValues(metrics.EnvironmentID, "overflow").Add(float64(dropped))
+		}
+	}
+
+	existing.TargetData = &mergedTargets
+	existing.MetricsData = &mergedMetrics
+	m.metrics[metrics.EnvironmentID] = existing
+}
+
+// dedupeTargetData merges b into a, keeping only the first TargetData seen
+// for each Identifier
+func dedupeTargetData(a, b *[]clientgen.TargetData) []clientgen.TargetData {
+	seen := map[string]struct{}{}
+	merged := []clientgen.TargetData{}
+
+	for _, list := range []*[]clientgen.TargetData{a, b} {
+		if list == nil {
+			continue
+		}
+		for _, td := range *list {
+			if _, ok := seen[td.Identifier]; ok {
+				continue
+			}
+			seen[td.Identifier] = struct{}{}
+			merged = append(merged, td)
+		}
+	}
+
+	return merged
+}
+
+// metricsDataBucketWindow is the width of the timestamp bucket that
+// MetricsData sharing a feature/variation/target is collapsed into
+const metricsDataBucketWindow = int64(60)
+
+// metricsDataKey identifies MetricsData entries that should be collapsed
+// into a single incremented Count
+type metricsDataKey struct {
+	featureIdentifier   string
+	variationIdentifier string
+	target              string
+	bucket              int64
+}
+
+// mergeMetricsData merges b into a, collapsing entries that share the same
+// featureIdentifier/variationIdentifier/target/timestamp-bucket into a
+// single entry with an incremented Count
+func mergeMetricsData(a, b *[]clientgen.MetricsData) []clientgen.MetricsData {
+	index := map[metricsDataKey]int{}
+	merged := []clientgen.MetricsData{}
+
+	add := func(md clientgen.MetricsData) {
+		key := metricsDataKey{
+			featureIdentifier:   attrValue(md.Attributes, "featureIdentifier"),
+			variationIdentifier: attrValue(md.Attributes, "variationIdentifier"),
+			target:              attrValue(md.Attributes, "target"),
+			bucket:              md.Timestamp / metricsDataBucketWindow,
+		}
+
+		if i, ok := index[key]; ok {
+			merged[i].Count += md.Count
+			return
+		}
+
+		index[key] = len(merged)
+		merged = append(merged, md)
+	}
+
+	for _, list := range []*[]clientgen.MetricsData{a, b} {
+		if list == nil {
+			continue
+		}
+		for _, md := range *list {
+			add(md)
+		}
+	}
+
+	return merged
+}
+
+// attrValue returns the value of the first KeyValue in attrs whose Key
+// matches, or "" if there isn't one
+func attrValue(attrs []clientgen.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return ""
+}
+
+// SendMetrics flushes buffered metrics for every environment, retrying
+// previously failed batches that are due and re-enqueueing any new failures
+// with exponential backoff up to maxSendAttempts before dropping them.
+func (m *MetricService) SendMetrics(ctx context.Context, clusterIdentifier string) {
+	m.lock.Lock()
+	batch := m.metrics
+	m.metrics = map[string]domain.MetricsRequest{}
+	m.lock.Unlock()
+
+	for env, metrics := range batch {
+		m.sendRecovered(ctx, env, metrics, 0)
+	}
+
+	m.retryDue(ctx)
+}
+
+// retryDue resends any retry-queued batches whose backoff has elapsed
+func (m *MetricService) retryDue(ctx context.Context) {
+	m.retryLock.Lock()
+	due := []retryBatch{}
+	remaining := m.retryQueue[:0]
+	now := time.Now()
+	for _, rb := range m.retryQueue {
+		if now.After(rb.nextAttempt) {
+			due = append(due, rb)
+			continue
+		}
+		remaining = append(remaining, rb)
+	}
+	m.retryQueue = remaining
+	m.retryLock.Unlock()
+
+	for _, rb := range due {
+		m.sendRecovered(ctx, rb.env, rb.metrics, rb.attempt)
+	}
+}
+
+// sendRecovered wraps send with panic recovery: a panic inside a sink's
+// Flush (e.g. a misbehaving OTLP exporter) is logged and the batch is
+// re-enqueued for retry rather than losing the batch and taking down
+// whichever goroutine is driving SendMetrics.
+func (m *MetricService) sendRecovered(ctx context.Context, env string, metrics domain.MetricsRequest, attempt int) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			m.logger.Error(fmt.Sprintf("recovered from panic sending metrics for env %s: %v", env, rec))
+
+			m.retryLock.Lock()
+			m.retryQueue = append(m.retryQueue, retryBatch{
+				env:         env,
+				metrics:     metrics,
+				attempt:     attempt + 1,
+				nextAttempt: time.Now().Add(backoff(attempt + 1)),
+			})
+			m.retryLock.Unlock()
+		}
+	}()
+
+	m.send(ctx, env, metrics, attempt)
+}
+
+// send flushes metrics for env to every configured sink. The Harness sink,
+// when enabled, is on the critical retry path: failures there re-enqueue the
+// batch with backoff, or drop it once maxSendAttempts has been exceeded. The
+// extra sinks (OTLP/Prometheus) are best effort - a failure there is logged
+// and doesn't affect the Harness retry/drop decision. Harness is skipped
+// entirely if disabled via WithHarnessDisabled, e.g. for air-gapped
+// deployments that only want the extra sinks.
+func (m *MetricService) send(ctx context.Context, env string, metrics domain.MetricsRequest, attempt int) {
+	if m.harness == nil {
+		m.flushExtraSinks(ctx, env, metrics)
+		return
+	}
+
+	err := m.harness.Flush(ctx, env, metrics)
+	if err == nil {
+		m.flushExtraSinks(ctx, env, metrics)
+		return
+	}
+
+	if attempt+1 >= maxSendAttempts {
+		reason := "error"
+		if errors.Is(err, errNonSuccessStatus) {
+			reason = "non-2xx"
+		}
+		metricsDropped.WithLabelValues(env, reason).Inc()
+		return
+	}
+
+	m.retryLock.Lock()
+	m.retryQueue = append(m.retryQueue, retryBatch{
+		env:         env,
+		metrics:     metrics,
+		attempt:     attempt + 1,
+		nextAttempt: time.Now().Add(backoff(attempt + 1)),
+	})
+	m.retryLock.Unlock()
+}
+
+// flushExtraSinks flushes metrics to every non-Harness sink, logging but
+// otherwise ignoring failures
+func (m *MetricService) flushExtraSinks(ctx context.Context, env string, metrics domain.MetricsRequest) {
+	for _, sink := range m.sinks {
+		if err := sink.Flush(ctx, env, metrics); err != nil {
+			m.logger.Error(fmt.Sprintf("failed to flush metrics sink for env %s: %v", env, err))
+		}
+	}
+}
+
+// backoff returns an exponential backoff duration for the given attempt,
+// capped at maxBackoff and jittered to avoid every environment retrying in
+// lockstep
+func backoff(attempt int) time.Duration {
+	d := minBackoff << attempt
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(d) / 2))
+	return d/2 + jitter
+}
+
+// addAuthToken adds the bearer token stashed in ctx to req's Authorization
+// header
+func addAuthToken(ctx context.Context, req *http.Request) error {
+	token, ok := ctx.Value(tokenKey).(string)
+	if !ok || token == "" {
+		return fmt.Errorf("no auth token exists in context")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	return nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/harness/ff-proxy/domain"
+	clientgen "github.com/harness/ff-proxy/gen/client"
+	"github.com/harness/ff-proxy/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricService_StoreMetrics_Overflow(t *testing.T) {
+	metricService := MetricService{
+		metrics:              map[string]domain.MetricsRequest{},
+		enabled:              true,
+		maxMetricsDataPerEnv: 1,
+	}
+
+	metricService.StoreMetrics(context.Background(), env123MetricsFlag1)
+	metricService.StoreMetrics(context.Background(), env123MetricsFlag2)
+
+	actual := metricService.metrics["123"]
+
+	// only the most recently stored MetricsData entry survives the bound
+	assert.Equal(t, 1, len(*actual.MetricsData))
+	assert.Equal(t, (*env123MetricsFlag2.MetricsData)[0], (*actual.MetricsData)[0])
+}
+
+func TestMetricService_SendMetrics_RetriesFailedBatch(t *testing.T) {
+	attempts := 0
+	logger, _ := log.NewStructuredLogger(true)
+	metricsService, _ := NewMetricService(logger, defaultMetricsURL, defaultAccount, map[string]string{"123": defaultToken}, true)
+	metricsService.metrics = map[string]domain.MetricsRequest{"123": env123MetricsFlag1}
+	metricsService.harness.client = mockService{
+		postMetricsWithResp: func(environment string) (*clientgen.PostMetricsResponse, error) {
+			attempts++
+			return nil, fmt.Errorf("stuff went wrong")
+		},
+	}
+
+	metricsService.SendMetrics(context.Background(), "1")
+
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 1, len(metricsService.retryQueue))
+	assert.Equal(t, "123", metricsService.retryQueue[0].env)
+	assert.Equal(t, 1, metricsService.retryQueue[0].attempt)
+}
+
+func TestMetricService_SendMetrics_HarnessDisabled(t *testing.T) {
+	logger, _ := log.NewStructuredLogger(true)
+	flushed := false
+	metricsService, _ := NewMetricService(logger, defaultMetricsURL, defaultAccount, map[string]string{"123": defaultToken}, true,
+		WithHarnessDisabled(),
+		WithSinks(sinkFunc(func(ctx context.Context, envID string, m domain.MetricsRequest) error {
+			flushed = true
+			return nil
+		})),
+	)
+	metricsService.metrics = map[string]domain.MetricsRequest{"123": env123MetricsFlag1}
+
+	metricsService.SendMetrics(context.Background(), "1")
+
+	assert.True(t, flushed, "expected the extra sink to still be flushed")
+	assert.Equal(t, 0, len(metricsService.retryQueue), "a disabled harness sink should never enqueue a retry")
+}
+
+func TestMetricService_SendMetrics_DropsAfterMaxAttempts(t *testing.T) {
+	attempts := 0
+	logger, _ := log.NewStructuredLogger(true)
+	metricsService, _ := NewMetricService(logger, defaultMetricsURL, defaultAccount, map[string]string{"123": defaultToken}, true)
+	metricsService.harness.client = mockService{
+		postMetricsWithResp: func(environment string) (*clientgen.PostMetricsResponse, error) {
+			attempts++
+			return nil, fmt.Errorf("stuff went wrong")
+		},
+	}
+
+	metricsService.send(context.Background(), "123", env123MetricsFlag1, maxSendAttempts-1)
+
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 0, len(metricsService.retryQueue))
+}
@@ -209,7 +209,7 @@ func TestMetricService_SendMetrics(t *testing.T) {
 			logger, _ := log.NewStructuredLogger(true)
 			metricsService, _ := NewMetricService(logger, defaultMetricsURL, defaultAccount, tc.tokens, true)
 			metricsService.metrics = tc.metrics
-			metricsService.client = mockService{postMetricsWithResp: tc.postMetricsWithResp}
+			metricsService.harness.client = mockService{postMetricsWithResp: tc.postMetricsWithResp}
 
 			metricsService.SendMetrics(context.Background(), "1")
 
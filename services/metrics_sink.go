@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/harness/ff-proxy/domain"
+	clientgen "github.com/harness/ff-proxy/gen/client"
+)
+
+// SinkName identifies a MetricsSink implementation, used to select which
+// sinks are active via the METRICS_SINKS env var
+type SinkName string
+
+const (
+	// SinkHarness is the Harness SaaS metrics endpoint
+	SinkHarness SinkName = "harness"
+	// SinkOTLP ships metrics to an OTLP collector
+	SinkOTLP SinkName = "otlp"
+	// SinkPrometheus exposes metrics as in-process counters on /metrics
+	SinkPrometheus SinkName = "prometheus"
+)
+
+// errNonSuccessStatus is returned by HarnessSink.Flush when the Harness SaaS
+// endpoint responds with a non-2xx status
+var errNonSuccessStatus = errors.New("non-2xx response from metrics endpoint")
+
+// MetricsSink ships a MetricsRequest for a single environment somewhere -
+// the Harness SaaS endpoint, an OTLP collector, or an in-process Prometheus
+// registry. Multiple sinks can be registered on a MetricService at once.
+type MetricsSink interface {
+	Flush(ctx context.Context, envID string, m domain.MetricsRequest) error
+}
+
+// ParseSinkNames parses the comma separated value of METRICS_SINKS, e.g.
+// "harness,otlp", trimming whitespace and dropping empty entries
+func ParseSinkNames(raw string) []SinkName {
+	var names []SinkName
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		names = append(names, SinkName(part))
+	}
+	return names
+}
+
+// BuildExtraSinks maps the non-Harness sink names parsed from METRICS_SINKS
+// to concrete MetricsSink instances, for use with MetricService's WithSinks
+// option. SinkHarness is ignored here - whether the Harness sink itself runs
+// is controlled separately via MetricService's WithHarnessDisabled, since
+// unlike OTLP/Prometheus it sits on the critical retry/drop path rather than
+// being best effort.
+func BuildExtraSinks(ctx context.Context, names []SinkName, otlpEndpoint string) ([]MetricsSink, error) {
+	var sinks []MetricsSink
+	for _, name := range names {
+		switch name {
+		case SinkHarness:
+			continue
+		case SinkOTLP:
+			otlp, err := NewOTLPSink(ctx, otlpEndpoint)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build otlp sink: %w", err)
+			}
+			sinks = append(sinks, otlp)
+		case SinkPrometheus:
+			sinks = append(sinks, NewPrometheusSink())
+		default:
+			return nil, fmt.Errorf("unknown metrics sink %q", name)
+		}
+	}
+
+	return sinks, nil
+}
+
+// HasSink reports whether names contains target, used to decide e.g. whether
+// MetricService's Harness sink should be disabled via WithHarnessDisabled
+func HasSink(names []SinkName, target SinkName) bool {
+	for _, name := range names {
+		if name == target {
+			return true
+		}
+	}
+	return false
+}
+
+// HarnessSink flushes metrics to the Harness SaaS metrics endpoint. This is
+// the original, and default, behaviour of MetricService.
+type HarnessSink struct {
+	client metricsClient
+	tokens map[string]string
+}
+
+// NewHarnessSink creates a HarnessSink that authenticates with the
+// per-environment tokens
+func NewHarnessSink(client metricsClient, tokens map[string]string) *HarnessSink {
+	return &HarnessSink{client: client, tokens: tokens}
+}
+
+// Flush POSTs m to the Harness SaaS metrics endpoint for envID. It's a no-op
+// if envID has no configured token.
+func (h *HarnessSink) Flush(ctx context.Context, envID string, m domain.MetricsRequest) error {
+	token, ok := h.tokens[envID]
+	if !ok {
+		return nil
+	}
+
+	ctx = context.WithValue(ctx, tokenKey, token)
+
+	resp, err := h.client.PostMetricsWithResponse(ctx, clientgen.EnvironmentPathParam(envID), &clientgen.PostMetricsParams{}, clientgen.PostMetricsJSONRequestBody(m.Metrics))
+	if err != nil {
+		return fmt.Errorf("failed to post metrics: %w", err)
+	}
+	if resp == nil || resp.HTTPResponse == nil || resp.HTTPResponse.StatusCode < 200 || resp.HTTPResponse.StatusCode >= 300 {
+		return errNonSuccessStatus
+	}
+
+	return nil
+}
+
+// OTLPSink converts MetricsData/TargetData into OTLP metrics and ships them
+// to a configurable collector over gRPC, for air-gapped deployments that
+// can't reach events.ff.harness.io but still want flag-evaluation telemetry.
+type OTLPSink struct {
+	exporter sdkmetric.Exporter
+
+	lock      sync.Mutex
+	lastFlush time.Time
+}
+
+// NewOTLPSink creates an OTLPSink that exports to the collector at endpoint
+func NewOTLPSink(ctx context.Context, endpoint string) (*OTLPSink, error) {
+	exporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithEndpoint(endpoint), otlpmetricgrpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+	}
+
+	return &OTLPSink{exporter: exporter, lastFlush: time.Now()}, nil
+}
+
+// Flush converts m's MetricsData into a single OTLP counter,
+// ff_proxy_feature_evaluations, with one data point per MetricsData entry
+// whose attributes are the environment plus the entry's KeyValue pairs (so
+// the feature identifier is an attribute), and exports it. MetricService zeroes its buffer after every send, so each
+// Flush only carries the count accumulated since the previous one - that's
+// a delta, not a running total, so it's exported with delta temporality
+// rather than cumulative to avoid collectors reading the per-flush jumps as
+// counter resets.
+func (o *OTLPSink) Flush(ctx context.Context, envID string, m domain.MetricsRequest) error {
+	if m.MetricsData == nil {
+		return nil
+	}
+
+	o.lock.Lock()
+	start := o.lastFlush
+	now := time.Now()
+	o.lastFlush = now
+	o.lock.Unlock()
+
+	var dataPoints []metricdata.DataPoint[int64]
+	for _, md := range *m.MetricsData {
+		attrs := []attribute.KeyValue{attribute.String("environment", envID)}
+		for _, kv := range md.Attributes {
+			attrs = append(attrs, attribute.String(kv.Key, kv.Value))
+		}
+
+		dataPoints = append(dataPoints, metricdata.DataPoint[int64]{
+			Attributes: attribute.NewSet(attrs...),
+			StartTime:  start,
+			Time:       now,
+			Value:      int64(md.Count),
+		})
+	}
+
+	rm := &metricdata.ResourceMetrics{
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Metrics: []metricdata.Metrics{{
+				Name: "ff_proxy_feature_evaluations",
+				Data: metricdata.Sum[int64]{
+					DataPoints:  dataPoints,
+					Temporality: metricdata.DeltaTemporality,
+					IsMonotonic: true,
+				},
+			}},
+		}},
+	}
+
+	if err := o.exporter.Export(ctx, rm); err != nil {
+		return fmt.Errorf("failed to export otlp metrics: %w", err)
+	}
+
+	return nil
+}
+
+var promFeatureEvaluations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ff_proxy_feature_evaluations_total",
+	Help: "Total number of feature evaluations observed in metrics sent by SDKs",
+}, []string{"env", "feature"})
+
+// PrometheusSink increments in-process counters exposed at /metrics on the
+// admin port, keyed by environment and feature identifier
+type PrometheusSink struct{}
+
+// NewPrometheusSink creates a PrometheusSink
+func NewPrometheusSink() *PrometheusSink {
+	return &PrometheusSink{}
+}
+
+// Flush increments promFeatureEvaluations for every MetricsData entry in m
+func (p *PrometheusSink) Flush(ctx context.Context, envID string, m domain.MetricsRequest) error {
+	if m.MetricsData == nil {
+		return nil
+	}
+
+	for _, md := range *m.MetricsData {
+		promFeatureEvaluations.WithLabelValues(envID, attrValue(md.Attributes, "featureIdentifier")).Add(float64(md.Count))
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/harness/ff-proxy/domain"
+	clientgen "github.com/harness/ff-proxy/gen/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// sinkFunc adapts a func to a MetricsSink, for stubbing out extra sinks in
+// tests without a dedicated mock type per test
+type sinkFunc func(ctx context.Context, envID string, m domain.MetricsRequest) error
+
+func (f sinkFunc) Flush(ctx context.Context, envID string, m domain.MetricsRequest) error {
+	return f(ctx, envID, m)
+}
+
+func TestParseSinkNames(t *testing.T) {
+	testCases := map[string]struct {
+		raw      string
+		expected []SinkName
+	}{
+		"Given an empty value": {
+			raw:      "",
+			expected: nil,
+		},
+		"Given a single sink": {
+			raw:      "harness",
+			expected: []SinkName{SinkHarness},
+		},
+		"Given multiple sinks with whitespace": {
+			raw:      "harness, otlp , prometheus",
+			expected: []SinkName{SinkHarness, SinkOTLP, SinkPrometheus},
+		},
+	}
+
+	for desc, tc := range testCases {
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ParseSinkNames(tc.raw))
+		})
+	}
+}
+
+func TestHasSink(t *testing.T) {
+	testCases := map[string]struct {
+		names    []SinkName
+		target   SinkName
+		expected bool
+	}{
+		"Given an empty names list": {
+			names:    nil,
+			target:   SinkHarness,
+			expected: false,
+		},
+		"Given names containing the target": {
+			names:    []SinkName{SinkOTLP, SinkHarness},
+			target:   SinkHarness,
+			expected: true,
+		},
+		"Given names not containing the target": {
+			names:    []SinkName{SinkOTLP, SinkPrometheus},
+			target:   SinkHarness,
+			expected: false,
+		},
+	}
+
+	for desc, tc := range testCases {
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, HasSink(tc.names, tc.target))
+		})
+	}
+}
+
+func TestBuildExtraSinks(t *testing.T) {
+	sinks, err := BuildExtraSinks(context.Background(), []SinkName{SinkHarness, SinkPrometheus}, "")
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	// SinkHarness is skipped - it's wired up separately via MetricService's
+	// harness field rather than as an extra sink
+	assert.Len(t, sinks, 1)
+	_, ok := sinks[0].(*PrometheusSink)
+	assert.True(t, ok)
+}
+
+func TestBuildExtraSinks_UnknownName(t *testing.T) {
+	_, err := BuildExtraSinks(context.Background(), []SinkName{SinkName("bogus")}, "")
+	assert.Error(t, err)
+}
+
+func TestHarnessSink_Flush(t *testing.T) {
+	testCases := map[string]struct {
+		tokens      map[string]string
+		respFn      func(environment string) (*clientgen.PostMetricsResponse, error)
+		expectedErr bool
+	}{
+		"Given no token for the environment we skip without error": {
+			tokens: map[string]string{},
+			respFn: func(environment string) (*clientgen.PostMetricsResponse, error) {
+				t.Fatalf("should not be called")
+				return nil, nil
+			},
+		},
+		"Given a non-2xx response we return errNonSuccessStatus": {
+			tokens: map[string]string{"123": "token"},
+			respFn: func(environment string) (*clientgen.PostMetricsResponse, error) {
+				return &clientgen.PostMetricsResponse{HTTPResponse: &http.Response{StatusCode: 500}}, nil
+			},
+			expectedErr: true,
+		},
+	}
+
+	for desc, tc := range testCases {
+		t.Run(desc, func(t *testing.T) {
+			sink := NewHarnessSink(mockService{postMetricsWithResp: tc.respFn}, tc.tokens)
+			err := sink.Flush(context.Background(), "123", domain.MetricsRequest{EnvironmentID: "123"})
+
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
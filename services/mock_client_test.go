@@ -0,0 +1,13 @@
+package services
+
+import (
+	"context"
+
+	clientgen "github.com/harness/ff-proxy/gen/client"
+)
+
+// mockService is a minimal metricsClient used to stub out calls to the
+// Harness SaaS metrics endpoint in tests
+type mockService struct {
+	postMetricsWithResp func(environment string) (*clientgen.PostMetricsResponse, error)
+}